@@ -0,0 +1,83 @@
+package main
+
+import (
+	"sync"
+	"time"
+
+	"golang.org/x/sync/singleflight"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/tkliner/nginx_push_stream_exporter/pushstream"
+)
+
+// statsCache memoizes a decoded pushstream.Stats for ttl and uses
+// singleflight so concurrent Collect calls that arrive while a fetch is in
+// flight share its result instead of each hitting the upstream nginx
+// endpoint. A ttl of 0 disables caching entirely.
+type statsCache struct {
+	ttl   time.Duration
+	group singleflight.Group
+
+	mu     sync.Mutex
+	stats  *pushstream.Stats
+	expiry time.Time
+
+	hits   prometheus.Counter
+	misses prometheus.Counter
+}
+
+func newStatsCache(ttl time.Duration) *statsCache {
+	return &statsCache{
+		ttl: ttl,
+		hits: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "push_stream_exporter",
+			Name:      "cache_hits_total",
+			Help:      "Number of scrapes served from the cached stats instead of fetching from nginx.",
+		}),
+		misses: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "push_stream_exporter",
+			Name:      "cache_misses_total",
+			Help:      "Number of scrapes that fetched fresh stats from nginx.",
+		}),
+	}
+}
+
+// Get returns the cached stats if still fresh, otherwise calls fetch,
+// de-duplicating concurrent callers via singleflight and caching the
+// result for ttl.
+func (c *statsCache) Get(key string, fetch func() (*pushstream.Stats, error)) (*pushstream.Stats, error) {
+	if c.ttl <= 0 {
+		return fetch()
+	}
+
+	c.mu.Lock()
+	if c.stats != nil && time.Now().Before(c.expiry) {
+		stats := c.stats
+		c.mu.Unlock()
+		c.hits.Inc()
+		return stats, nil
+	}
+	c.mu.Unlock()
+
+	v, err, _ := c.group.Do(key, func() (interface{}, error) {
+		c.misses.Inc()
+		stats, err := fetch()
+		if err != nil {
+			return nil, err
+		}
+
+		c.mu.Lock()
+		c.stats = stats
+		c.expiry = time.Now().Add(c.ttl)
+		c.mu.Unlock()
+
+		return stats, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return v.(*pushstream.Stats), nil
+}
@@ -0,0 +1,151 @@
+package main
+
+import (
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/tkliner/nginx_push_stream_exporter/pushstream"
+)
+
+func TestStatsCacheDisabledFetchesEveryTime(t *testing.T) {
+	c := newStatsCache(0)
+
+	calls := 0
+	fetch := func() (*pushstream.Stats, error) {
+		calls++
+		return &pushstream.Stats{Channels: int64(calls)}, nil
+	}
+
+	for i := 0; i < 3; i++ {
+		if _, err := c.Get("key", fetch); err != nil {
+			t.Fatalf("Get() error: %v", err)
+		}
+	}
+
+	if calls != 3 {
+		t.Errorf("calls = %d, want 3 (ttl=0 must disable caching)", calls)
+	}
+}
+
+func TestStatsCacheServesWithinTTL(t *testing.T) {
+	c := newStatsCache(time.Hour)
+
+	calls := 0
+	fetch := func() (*pushstream.Stats, error) {
+		calls++
+		return &pushstream.Stats{Channels: int64(calls)}, nil
+	}
+
+	first, err := c.Get("key", fetch)
+	if err != nil {
+		t.Fatalf("Get() error: %v", err)
+	}
+	second, err := c.Get("key", fetch)
+	if err != nil {
+		t.Fatalf("Get() error: %v", err)
+	}
+
+	if calls != 1 {
+		t.Errorf("calls = %d, want 1 (second Get should be served from cache)", calls)
+	}
+	if first != second {
+		t.Errorf("expected the cached *Stats to be returned unchanged, got %+v and %+v", first, second)
+	}
+}
+
+func TestStatsCacheExpiresAfterTTL(t *testing.T) {
+	c := newStatsCache(time.Millisecond)
+
+	calls := 0
+	fetch := func() (*pushstream.Stats, error) {
+		calls++
+		return &pushstream.Stats{Channels: int64(calls)}, nil
+	}
+
+	if _, err := c.Get("key", fetch); err != nil {
+		t.Fatalf("Get() error: %v", err)
+	}
+
+	time.Sleep(10 * time.Millisecond)
+
+	if _, err := c.Get("key", fetch); err != nil {
+		t.Fatalf("Get() error: %v", err)
+	}
+
+	if calls != 2 {
+		t.Errorf("calls = %d, want 2 (entry should have expired)", calls)
+	}
+}
+
+func TestStatsCacheFetchErrorIsNotCached(t *testing.T) {
+	c := newStatsCache(time.Hour)
+
+	calls := 0
+	failFirst := func() (*pushstream.Stats, error) {
+		calls++
+		if calls == 1 {
+			return nil, errFetch
+		}
+		return &pushstream.Stats{Channels: int64(calls)}, nil
+	}
+
+	if _, err := c.Get("key", failFirst); err == nil {
+		t.Fatal("Get() error = nil, want error from first fetch")
+	}
+
+	stats, err := c.Get("key", failFirst)
+	if err != nil {
+		t.Fatalf("Get() error: %v", err)
+	}
+	if stats.Channels != 2 {
+		t.Errorf("Channels = %d, want 2 (a failed fetch must not poison the cache)", stats.Channels)
+	}
+}
+
+func TestStatsCacheDeduplicatesConcurrentFetches(t *testing.T) {
+	c := newStatsCache(time.Hour)
+
+	var calls int32
+	release := make(chan struct{})
+	fetch := func() (*pushstream.Stats, error) {
+		atomic.AddInt32(&calls, 1)
+		<-release
+		return &pushstream.Stats{Channels: 1}, nil
+	}
+
+	const callers = 5
+	var wg sync.WaitGroup
+	results := make([]*pushstream.Stats, callers)
+	errs := make([]error, callers)
+	for i := 0; i < callers; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i], errs[i] = c.Get("key", fetch)
+		}(i)
+	}
+
+	time.Sleep(50 * time.Millisecond) // let every goroutine reach singleflight.Do
+	close(release)
+	wg.Wait()
+
+	if got := atomic.LoadInt32(&calls); got != 1 {
+		t.Errorf("fetch called %d times, want 1 (singleflight should de-dupe concurrent callers)", got)
+	}
+	for i := range results {
+		if errs[i] != nil {
+			t.Fatalf("Get() [%d] error: %v", i, errs[i])
+		}
+		if results[i] == nil || results[i].Channels != 1 {
+			t.Errorf("Get() [%d] = %+v, want Channels=1", i, results[i])
+		}
+	}
+}
+
+type fetchError string
+
+func (e fetchError) Error() string { return string(e) }
+
+const errFetch = fetchError("fetch failed")
@@ -0,0 +1,81 @@
+package main
+
+import (
+	"regexp"
+
+	"github.com/tkliner/nginx_push_stream_exporter/pushstream"
+)
+
+// ChannelFilter bounds the label cardinality the exporter exposes for
+// per-channel metrics. push_stream deployments routinely have tens or
+// hundreds of thousands of ephemeral channels, which left unfiltered would
+// turn into that many distinct "channel" label values in Prometheus.
+type ChannelFilter struct {
+	Include     *regexp.Regexp
+	Exclude     *regexp.Regexp
+	Aggregate   *regexp.Regexp
+	MaxChannels int
+}
+
+// Apply runs the include/exclude filter, then aggregation, then the hard
+// cap, returning the resulting channels and the number dropped by the cap.
+func (f ChannelFilter) Apply(infos []pushstream.ChannelStats) ([]pushstream.ChannelStats, int64) {
+	kept := make([]pushstream.ChannelStats, 0, len(infos))
+	for _, c := range infos {
+		if f.Include != nil && !f.Include.MatchString(c.Name) {
+			continue
+		}
+		if f.Exclude != nil && f.Exclude.MatchString(c.Name) {
+			continue
+		}
+		kept = append(kept, c)
+	}
+
+	if f.Aggregate != nil {
+		kept = aggregateChannels(kept, f.Aggregate)
+	}
+
+	var dropped int64
+	if f.MaxChannels > 0 && len(kept) > f.MaxChannels {
+		dropped = int64(len(kept) - f.MaxChannels)
+		kept = kept[:f.MaxChannels]
+	}
+
+	return kept, dropped
+}
+
+// aggregateChannels collapses channels whose name matches re into a single
+// entry per capture group, summing their counters. Channels that don't
+// match re are passed through unchanged.
+func aggregateChannels(infos []pushstream.ChannelStats, re *regexp.Regexp) []pushstream.ChannelStats {
+	order := make([]string, 0, len(infos))
+	groups := map[string]*pushstream.ChannelStats{}
+	passthrough := make([]pushstream.ChannelStats, 0)
+
+	for _, c := range infos {
+		match := re.FindStringSubmatch(c.Name)
+		if len(match) < 2 {
+			passthrough = append(passthrough, c)
+			continue
+		}
+
+		key := match[1]
+		if g, ok := groups[key]; ok {
+			g.PublishedMessages += c.PublishedMessages
+			g.StoredMessages += c.StoredMessages
+			g.Subscribers += c.Subscribers
+			continue
+		}
+
+		group := c
+		group.Name = key
+		groups[key] = &group
+		order = append(order, key)
+	}
+
+	result := make([]pushstream.ChannelStats, 0, len(order)+len(passthrough))
+	for _, key := range order {
+		result = append(result, *groups[key])
+	}
+	return append(result, passthrough...)
+}
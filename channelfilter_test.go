@@ -0,0 +1,125 @@
+package main
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/tkliner/nginx_push_stream_exporter/pushstream"
+)
+
+func channels(names ...string) []pushstream.ChannelStats {
+	infos := make([]pushstream.ChannelStats, len(names))
+	for i, n := range names {
+		infos[i] = pushstream.ChannelStats{Name: n, PublishedMessages: 1, StoredMessages: 1, Subscribers: 1}
+	}
+	return infos
+}
+
+func names(infos []pushstream.ChannelStats) []string {
+	out := make([]string, len(infos))
+	for i, c := range infos {
+		out[i] = c.Name
+	}
+	return out
+}
+
+func TestChannelFilterApply(t *testing.T) {
+	tests := []struct {
+		name        string
+		filter      ChannelFilter
+		infos       []pushstream.ChannelStats
+		wantNames   []string
+		wantDropped int64
+	}{
+		{
+			name:      "no filter keeps everything",
+			filter:    ChannelFilter{},
+			infos:     channels("a", "b", "c"),
+			wantNames: []string{"a", "b", "c"},
+		},
+		{
+			name:      "include keeps only matches",
+			filter:    ChannelFilter{Include: regexp.MustCompile(`^user\.`)},
+			infos:     channels("user.1", "user.2", "room.1"),
+			wantNames: []string{"user.1", "user.2"},
+		},
+		{
+			name:      "exclude drops matches",
+			filter:    ChannelFilter{Exclude: regexp.MustCompile(`^internal\.`)},
+			infos:     channels("internal.debug", "user.1"),
+			wantNames: []string{"user.1"},
+		},
+		{
+			name:        "max channels caps and counts drops",
+			filter:      ChannelFilter{MaxChannels: 2},
+			infos:       channels("a", "b", "c", "d"),
+			wantNames:   []string{"a", "b"},
+			wantDropped: 2,
+		},
+		{
+			name: "include, exclude, aggregate and cap compose",
+			filter: ChannelFilter{
+				Include:     regexp.MustCompile(`^room\.`),
+				Exclude:     regexp.MustCompile(`\.internal$`),
+				Aggregate:   regexp.MustCompile(`^(room\.\w+)\.\d+$`),
+				MaxChannels: 1,
+			},
+			infos: channels(
+				"room.lobby.1", "room.lobby.2", "room.game.1",
+				"room.lobby.internal", "user.1",
+			),
+			wantNames:   []string{"room.lobby"},
+			wantDropped: 1,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			got, dropped := tc.filter.Apply(tc.infos)
+			if dropped != tc.wantDropped {
+				t.Errorf("dropped = %d, want %d", dropped, tc.wantDropped)
+			}
+			gotNames := names(got)
+			if len(gotNames) != len(tc.wantNames) {
+				t.Fatalf("names = %v, want %v", gotNames, tc.wantNames)
+			}
+			for i, n := range gotNames {
+				if n != tc.wantNames[i] {
+					t.Errorf("names = %v, want %v", gotNames, tc.wantNames)
+					break
+				}
+			}
+		})
+	}
+}
+
+func TestAggregateChannelsSumsCounters(t *testing.T) {
+	infos := []pushstream.ChannelStats{
+		{Name: "room.lobby.1", PublishedMessages: 10, StoredMessages: 1, Subscribers: 2},
+		{Name: "room.lobby.2", PublishedMessages: 20, StoredMessages: 2, Subscribers: 3},
+		{Name: "room.other", PublishedMessages: 5, StoredMessages: 0, Subscribers: 1},
+	}
+
+	got := aggregateChannels(infos, regexp.MustCompile(`^(room\.lobby)\.\d+$`))
+
+	var lobby, other *pushstream.ChannelStats
+	for i := range got {
+		switch got[i].Name {
+		case "room.lobby":
+			lobby = &got[i]
+		case "room.other":
+			other = &got[i]
+		}
+	}
+
+	if lobby == nil {
+		t.Fatalf("expected an aggregated room.lobby entry, got %+v", got)
+	}
+	if lobby.PublishedMessages != 30 || lobby.StoredMessages != 3 || lobby.Subscribers != 5 {
+		t.Errorf("aggregated room.lobby = %+v, want sums of 30/3/5", *lobby)
+	}
+
+	if other == nil {
+		t.Fatalf("expected room.other to pass through unaggregated, got %+v", got)
+	}
+}
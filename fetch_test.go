@@ -0,0 +1,161 @@
+package main
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFetchHTTPBasicAuth(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		user, pass, ok := r.BasicAuth()
+		if !ok || user != "alice" || pass != "secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	clientConfig := HTTPClientConfig{BasicAuth: &BasicAuthConfig{User: "alice", Pass: "secret"}}
+	fetch, err := fetchHTTP(srv.URL, time.Second, clientConfig)
+	if err != nil {
+		t.Fatalf("fetchHTTP() error: %v", err)
+	}
+
+	body, err := fetch()
+	if err != nil {
+		t.Fatalf("fetch() error: %v", err)
+	}
+	defer body.Close()
+}
+
+func TestFetchHTTPBasicAuthRejected(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+	}))
+	defer srv.Close()
+
+	fetch, err := fetchHTTP(srv.URL, time.Second, HTTPClientConfig{})
+	if err != nil {
+		t.Fatalf("fetchHTTP() error: %v", err)
+	}
+
+	if _, err := fetch(); err == nil {
+		t.Fatal("fetch() error = nil, want error for 401 response")
+	}
+}
+
+func TestFetchHTTPBearerToken(t *testing.T) {
+	dir := t.TempDir()
+	tokenFile := filepath.Join(dir, "token")
+	if err := os.WriteFile(tokenFile, []byte("s3cr3t\n"), 0600); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer s3cr3t" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	clientConfig := HTTPClientConfig{BearerTokenFile: tokenFile}
+	fetch, err := fetchHTTP(srv.URL, time.Second, clientConfig)
+	if err != nil {
+		t.Fatalf("fetchHTTP() error: %v", err)
+	}
+
+	body, err := fetch()
+	if err != nil {
+		t.Fatalf("fetch() error: %v", err)
+	}
+	defer body.Close()
+}
+
+func TestFetchHTTPBearerTokenFileMissing(t *testing.T) {
+	clientConfig := HTTPClientConfig{BearerTokenFile: filepath.Join(t.TempDir(), "missing")}
+	if _, err := fetchHTTP("http://example.invalid", time.Second, clientConfig); err == nil {
+		t.Fatal("fetchHTTP() error = nil, want error for missing bearer token file")
+	}
+}
+
+func TestFetchHTTPTLSInsecureSkipVerify(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	fetch, err := fetchHTTP(srv.URL, time.Second, HTTPClientConfig{InsecureSkipVerify: true})
+	if err != nil {
+		t.Fatalf("fetchHTTP() error: %v", err)
+	}
+	if _, err := fetch(); err != nil {
+		t.Errorf("fetch() error = %v, want nil with InsecureSkipVerify", err)
+	}
+}
+
+func TestFetchHTTPTLSVerifyFailsWithoutCA(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	fetch, err := fetchHTTP(srv.URL, time.Second, HTTPClientConfig{})
+	if err != nil {
+		t.Fatalf("fetchHTTP() error: %v", err)
+	}
+	if _, err := fetch(); err == nil {
+		t.Fatal("fetch() error = nil, want a certificate verification error")
+	}
+}
+
+func TestHTTPClientConfigTLSConfigWithCAFile(t *testing.T) {
+	srv := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("ok"))
+	}))
+	defer srv.Close()
+
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: srv.Certificate().Raw})
+	if err := os.WriteFile(caFile, caPEM, 0644); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+
+	fetch, err := fetchHTTP(srv.URL, time.Second, HTTPClientConfig{CAFile: caFile})
+	if err != nil {
+		t.Fatalf("fetchHTTP() error: %v", err)
+	}
+	body, err := fetch()
+	if err != nil {
+		t.Fatalf("fetch() error: %v, want nil once the server cert's CA is trusted", err)
+	}
+	defer body.Close()
+}
+
+func TestHTTPClientConfigTLSConfigBadCAFile(t *testing.T) {
+	dir := t.TempDir()
+	caFile := filepath.Join(dir, "ca.pem")
+	if err := os.WriteFile(caFile, []byte("not a pem file"), 0644); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+
+	cfg := HTTPClientConfig{CAFile: caFile}
+	if _, err := cfg.tlsConfig(); err == nil {
+		t.Fatal("tlsConfig() error = nil, want error for unparsable CA file")
+	}
+}
+
+func TestHTTPClientConfigTLSConfigMissingCertFile(t *testing.T) {
+	cfg := HTTPClientConfig{CertFile: filepath.Join(t.TempDir(), "missing.crt"), KeyFile: filepath.Join(t.TempDir(), "missing.key")}
+	if _, err := cfg.tlsConfig(); err == nil {
+		t.Fatal("tlsConfig() error = nil, want error for unreadable client cert/key")
+	}
+}
@@ -1,18 +1,18 @@
 package main
 
 import (
-	"encoding/json"
+	"crypto/tls"
+	"crypto/x509"
 	"flag"
 	"fmt"
 	"github.com/tkliner/nginx_push_stream_exporter/pushstream"
 	"io"
+	"io/ioutil"
 	"net/http"
 	"net/url"
-	"reflect"
+	"regexp"
 	"sort"
-	"strconv"
 	"strings"
-	"sync"
 	"time"
 
 	"github.com/prometheus/client_golang/prometheus"
@@ -62,20 +62,128 @@ var (
 	nginxUp = prometheus.NewDesc(prometheus.BuildFQName(namespace, "", "up"), "Was the last scrape of nginx successful.", nil, nil)
 )
 
+// serverMetricFunc extracts a single server-wide sample from decoded stats.
+type serverMetricFunc func(*pushstream.Stats) float64
+
+// channelMetricFunc extracts a single sample from one channel's stats.
+type channelMetricFunc func(pushstream.ChannelStats) float64
+
+// serverMetricExtractors is the source of truth for server-wide metrics:
+// the field each exported metric name reads from pushstream.Stats.
+var serverMetricExtractors = map[string]serverMetricFunc{
+	"channels": func(s *pushstream.Stats) float64 { return float64(s.Channels) },
+	"subscribers_total": func(s *pushstream.Stats) float64 {
+		var total int64
+		for _, c := range s.Infos {
+			total += c.Subscribers
+		}
+		return float64(total)
+	},
+}
+
+// channelMetricExtractors is the source of truth for per-channel metrics:
+// the field each exported metric name reads from a pushstream.ChannelStats.
+var channelMetricExtractors = map[string]channelMetricFunc{
+	"published_messages": func(c pushstream.ChannelStats) float64 { return float64(c.PublishedMessages) },
+	"stored_messages":    func(c pushstream.ChannelStats) float64 { return float64(c.StoredMessages) },
+	"subscribers":        func(c pushstream.ChannelStats) float64 { return float64(c.Subscribers) },
+}
+
+// serverMetric pairs a Desc with the extractor that produces its value.
+type serverMetric struct {
+	desc    *prometheus.Desc
+	extract serverMetricFunc
+}
+
+// channelMetric pairs a Desc with the extractor that produces its value.
+type channelMetric struct {
+	desc    *prometheus.Desc
+	extract channelMetricFunc
+}
+
+// buildMetricRegistry splits the selected metrics into the typed
+// server/channel extractor lists the collector walks on every scrape.
+func buildMetricRegistry(selected map[string]*prometheus.Desc) ([]serverMetric, []channelMetric) {
+	var serverMetrics []serverMetric
+	var channelMetrics []channelMetric
+
+	for name, desc := range selected {
+		if extract, ok := serverMetricExtractors[name]; ok {
+			serverMetrics = append(serverMetrics, serverMetric{desc: desc, extract: extract})
+		}
+		if extract, ok := channelMetricExtractors[name]; ok {
+			channelMetrics = append(channelMetrics, channelMetric{desc: desc, extract: extract})
+		}
+	}
+
+	return serverMetrics, channelMetrics
+}
+
+// BasicAuthConfig holds HTTP basic-auth credentials to send to the scrape
+// target.
+type BasicAuthConfig struct {
+	User string `yaml:"user"`
+	Pass string `yaml:"pass"`
+}
+
+// HTTPClientConfig controls how the exporter talks to the push_stream stats
+// endpoint: TLS verification/certificates and optional auth.
+type HTTPClientConfig struct {
+	InsecureSkipVerify bool             `yaml:"insecure_skip_verify"`
+	CAFile             string           `yaml:"ca_file"`
+	CertFile           string           `yaml:"cert_file"`
+	KeyFile            string           `yaml:"key_file"`
+	BasicAuth          *BasicAuthConfig `yaml:"basic_auth"`
+	BearerTokenFile    string           `yaml:"bearer_token_file"`
+}
+
+// tlsConfig builds a *tls.Config from the HTTPClientConfig, loading the CA
+// and client certificate/key from disk if configured.
+func (c HTTPClientConfig) tlsConfig() (*tls.Config, error) {
+	cfg := &tls.Config{InsecureSkipVerify: c.InsecureSkipVerify}
+
+	if c.CAFile != "" {
+		ca, err := ioutil.ReadFile(c.CAFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read CA file: %v", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(ca) {
+			return nil, fmt.Errorf("unable to parse CA file %q", c.CAFile)
+		}
+		cfg.RootCAs = pool
+	}
+
+	if c.CertFile != "" || c.KeyFile != "" {
+		cert, err := tls.LoadX509KeyPair(c.CertFile, c.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to load client cert/key: %v", err)
+		}
+		cfg.Certificates = []tls.Certificate{cert}
+	}
+
+	return cfg, nil
+}
+
 // Exporter collects Nginx pushStream stats from the given URI and exports them using
 // the prometheus metrics package.
 type Exporter struct {
-	URI   string
-	mutex sync.RWMutex
-	fetch func() (io.ReadCloser, error)
+	URI     string
+	fetch   func() (io.ReadCloser, error)
+	decoder pushstream.StatsDecoder
 
 	up                prometheus.Gauge
 	totalScrapes      prometheus.Counter
+	channelsDropped   prometheus.Counter
 	pushStreamMetrics map[string]*prometheus.Desc
+	serverMetrics     []serverMetric
+	channelMetrics    []channelMetric
+	channelFilter     ChannelFilter
+	cache             *statsCache
 }
 
 // NewExporter returns an initialized Exporter.
-func NewExporter(uri string, selectedPushStreamMetrics map[string]*prometheus.Desc, timeout time.Duration) (*Exporter, error) {
+func NewExporter(uri string, selectedPushStreamMetrics map[string]*prometheus.Desc, timeout time.Duration, clientConfig HTTPClientConfig, module string, channelFilter ChannelFilter, cacheTTL time.Duration) (*Exporter, error) {
 	u, err := url.Parse(uri)
 	if err != nil {
 		return nil, err
@@ -84,14 +192,25 @@ func NewExporter(uri string, selectedPushStreamMetrics map[string]*prometheus.De
 	var fetch func() (io.ReadCloser, error)
 	switch u.Scheme {
 	case "http", "https", "file":
-		fetch = fetchHTTP(uri, timeout)
+		fetch, err = fetchHTTP(uri, timeout, clientConfig)
+		if err != nil {
+			return nil, err
+		}
 	default:
 		return nil, fmt.Errorf("unsupported scheme: %q", u.Scheme)
 	}
 
+	decoder, err := pushstream.NewDecoder(module)
+	if err != nil {
+		return nil, err
+	}
+
+	serverMetrics, channelMetrics := buildMetricRegistry(selectedPushStreamMetrics)
+
 	return &Exporter{
-		URI:   uri,
-		fetch: fetch,
+		URI:     uri,
+		fetch:   fetch,
+		decoder: decoder,
 		up: prometheus.NewGauge(prometheus.GaugeOpts{
 			Namespace: namespace,
 			Name:      "up",
@@ -102,7 +221,17 @@ func NewExporter(uri string, selectedPushStreamMetrics map[string]*prometheus.De
 			Name:      "exporter_total_scrapes",
 			Help:      "Current total nginx scrapes.",
 		}),
+		channelsDropped: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "push_stream",
+			Name:      "channels_dropped",
+			Help:      "Total number of channels dropped across scrapes because nginx.max-channels was exceeded.",
+		}),
 		pushStreamMetrics: selectedPushStreamMetrics,
+		serverMetrics:     serverMetrics,
+		channelMetrics:    channelMetrics,
+		channelFilter:     channelFilter,
+		cache:             newStatsCache(cacheTTL),
 	}, nil
 }
 
@@ -114,28 +243,57 @@ func (e *Exporter) Describe(ch chan<- *prometheus.Desc) {
 	}
 	ch <- nginxUp
 	ch <- e.totalScrapes.Desc()
+	ch <- e.channelsDropped.Desc()
+	ch <- e.cache.hits.Desc()
+	ch <- e.cache.misses.Desc()
 }
 
 // Collect fetches the stats from configured nginx location and delivers them
 // as Prometheus metrics. It implements prometheus.Collector.
 func (e *Exporter) Collect(ch chan<- prometheus.Metric) {
-	e.mutex.Lock() // To protect metrics from concurrent collects.
-	defer e.mutex.Unlock()
-
 	up := e.scrape(ch)
 	ch <- prometheus.MustNewConstMetric(nginxUp, prometheus.GaugeValue, up)
 	ch <- e.totalScrapes
+	ch <- e.channelsDropped
+	ch <- e.cache.hits
+	ch <- e.cache.misses
 }
 
-func fetchHTTP(uri string, timeout time.Duration) func() (io.ReadCloser, error) {
-	tr := &http.Transport{}
+func fetchHTTP(uri string, timeout time.Duration, clientConfig HTTPClientConfig) (func() (io.ReadCloser, error), error) {
+	tlsCfg, err := clientConfig.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	tr := &http.Transport{TLSClientConfig: tlsCfg}
 	client := http.Client{
 		Timeout:   timeout,
 		Transport: tr,
 	}
 
+	var bearerToken string
+	if clientConfig.BearerTokenFile != "" {
+		b, err := ioutil.ReadFile(clientConfig.BearerTokenFile)
+		if err != nil {
+			return nil, fmt.Errorf("unable to read bearer token file: %v", err)
+		}
+		bearerToken = strings.TrimSpace(string(b))
+	}
+
 	return func() (io.ReadCloser, error) {
-		resp, err := client.Get(uri)
+		req, err := http.NewRequest("GET", uri, nil)
+		if err != nil {
+			return nil, err
+		}
+
+		if clientConfig.BasicAuth != nil {
+			req.SetBasicAuth(clientConfig.BasicAuth.User, clientConfig.BasicAuth.Pass)
+		}
+		if bearerToken != "" {
+			req.Header.Set("Authorization", "Bearer "+bearerToken)
+		}
+
+		resp, err := client.Do(req)
 		if err != nil {
 			return nil, err
 		}
@@ -145,74 +303,69 @@ func fetchHTTP(uri string, timeout time.Duration) func() (io.ReadCloser, error)
 		}
 
 		return resp.Body, nil
-	}
+	}, nil
 }
 
 func (e *Exporter) scrape(ch chan<- prometheus.Metric) (up float64) {
-	var subscribersTotal int64
+	stats, err := e.cache.Get(e.URI, e.fetchAndDecode)
+	if err != nil {
+		log.Errorf("Can't scrape Nginx PushStream: %v", err)
+		return 0
+	}
+
+	// stats may be shared with concurrent scrapes via the cache, so filter
+	// into a separate slice rather than mutating the cached one. Server-wide
+	// extractors (e.g. subscribers_total) still read from the unfiltered
+	// stats, so channel filtering never changes a server-wide total.
+	infos, dropped := e.channelFilter.Apply(stats.Infos)
+	e.channelsDropped.Add(float64(dropped))
+
+	for _, m := range e.emit(stats, infos) {
+		ch <- m
+	}
+
+	return 1
+}
 
+// fetchAndDecode performs one uncached fetch of the upstream stats
+// endpoint and decodes its body. It's the only path that counts as a real
+// scrape of nginx, so totalScrapes is incremented here rather than in
+// scrape, which also runs on cache hits that never touch nginx.
+func (e *Exporter) fetchAndDecode() (*pushstream.Stats, error) {
 	e.totalScrapes.Inc()
 
 	body, err := e.fetch()
-
 	if err != nil {
-		log.Errorf("Can't scrape Nginx PushStream: %v", err)
-		return 0
+		return nil, err
 	}
 	defer body.Close()
 
-	ps := pushstream.NewPushStream()
-	jsonError := json.NewDecoder(body).Decode(&ps)
+	return e.decoder.Decode(body)
+}
 
-	if jsonError != nil {
-		log.Errorf("Unexpected error while reading JSON: %v", jsonError)
-		return 0
+// emit turns decoded stats into Prometheus metrics using the typed
+// server/channel extractor registries built at construction time, with no
+// reflection involved. The result slice is pre-allocated to its final size
+// up front, since the channel count is known before any extractor runs.
+//
+// stats feeds the server-wide extractors (e.g. subscribers_total) and must
+// be the unfiltered decode result, so channel include/exclude/max-channels
+// filtering can never change a server-wide total; channels feeds the
+// per-channel extractors and is the post-filter list actually emitted.
+func (e *Exporter) emit(stats *pushstream.Stats, channels []pushstream.ChannelStats) []prometheus.Metric {
+	metrics := make([]prometheus.Metric, 0, len(e.serverMetrics)+len(channels)*len(e.channelMetrics))
+
+	for _, m := range e.serverMetrics {
+		metrics = append(metrics, prometheus.MustNewConstMetric(m.desc, prometheus.GaugeValue, m.extract(stats), "all"))
 	}
 
-	v := reflect.ValueOf(ps).Elem()
-
-	for key, val := range e.pushStreamMetrics {
-		for i := 0; i < v.NumField(); i++ {
-			valueField := v.Field(i)
-			typeField := v.Type().Field(i)
-			switch valueField.Interface().(type) {
-			case int64:
-				if key == typeField.Tag.Get("json") {
-					ch <- prometheus.MustNewConstMetric(val, prometheus.GaugeValue, float64(valueField.Int()), "all")
-				}
-			case []*pushstream.Channel:
-				for _, info := range ps.Infos {
-					infoValue := reflect.ValueOf(info).Elem()
-
-					for e := 0; e < infoValue.NumField(); e++ {
-						valueInfoField := infoValue.Field(e)
-						typeInfoField := infoValue.Type().Field(e)
-						if key == typeInfoField.Tag.Get("json") {
-							ch <- prometheus.MustNewConstMetric(val, prometheus.GaugeValue, float64(valueInfoField.Int()), info.Channel)
-						}
-
-						if key == "subscribers_total" && typeInfoField.Tag.Get("json") == "subscribers" {
-							switch valueInfoField.Interface().(type) {
-							case string:
-								if n, err := strconv.Atoi(valueInfoField.String()); err == nil {
-									subscribersTotal += int64(n)
-								} else {
-									fmt.Println(v, "is not an integer.")
-									subscribersTotal += 0
-								}
-							case int64:
-								subscribersTotal += valueInfoField.Int()
-							}
-						}
-					}
-				}
-			}
+	for _, c := range channels {
+		for _, m := range e.channelMetrics {
+			metrics = append(metrics, prometheus.MustNewConstMetric(m.desc, prometheus.GaugeValue, m.extract(c), c.Name))
 		}
 	}
 
-	ch <- prometheus.MustNewConstMetric(e.pushStreamMetrics["subscribers_total"], prometheus.GaugeValue, float64(subscribersTotal), "all")
-
-	return 1
+	return metrics
 }
 
 // filterMetrics returns the set of pushStream metrics specified by the comma
@@ -238,11 +391,25 @@ func filterMetrics(filter string) map[string]*prometheus.Desc {
 
 func main() {
 	var (
-		listenAddress     = flag.String("web.listen-address", ":9101", "Address to listen on for web interface and telemetry.")
-		metricsPath       = flag.String("web.telemetry-path", "/metrics", "Path under which to expose metrics.")
-		nginxScrapeURI    = flag.String("nginx.scrape-uri", "http://localhost:8080/channels-stats?id=ALL", "URI on which to scrape Nginx PushStream channel stats.")
-		nginxMetricFields = flag.String("nginx.metric-fields", pushStreamMetrics.String(), "Comma-separated list of exported server metrics.")
-		nginxTimeout      = flag.Duration("nginx.timeout", time.Duration(5*time.Second), "Timeout for trying to get stats from nginx.")
+		listenAddress         = flag.String("web.listen-address", ":9101", "Address to listen on for web interface and telemetry.")
+		metricsPath           = flag.String("web.telemetry-path", "/metrics", "Path under which to expose metrics.")
+		nginxScrapeURI        = flag.String("nginx.scrape-uri", "http://localhost:8080/channels-stats?id=ALL", "URI on which to scrape Nginx PushStream channel stats.")
+		nginxMetricFields     = flag.String("nginx.metric-fields", pushStreamMetrics.String(), "Comma-separated list of exported server metrics.")
+		nginxTimeout          = flag.Duration("nginx.timeout", time.Duration(5*time.Second), "Timeout for trying to get stats from nginx.")
+		insecure              = flag.Bool("insecure", false, "Ignore server certificate if using https.")
+		tlsCAFile             = flag.String("tls.cacert", "", "Path to a CA certificate file used to verify the nginx server certificate.")
+		tlsCertFile           = flag.String("tls.cert", "", "Path to a client certificate file for mutual TLS.")
+		tlsKeyFile            = flag.String("tls.key", "", "Path to a client key file for mutual TLS.")
+		authUser              = flag.String("auth.user", "", "Username for HTTP basic auth against the nginx stats endpoint.")
+		authPass              = flag.String("auth.pass", "", "Password for HTTP basic auth against the nginx stats endpoint.")
+		authBearerToken       = flag.String("auth.bearer-token-file", "", "Path to a file containing a bearer token to send to the nginx stats endpoint.")
+		nginxModule           = flag.String("nginx.module", "push_stream", "Upstream stats schema to decode: push_stream, plus or stub_status.")
+		nginxChannelInclude   = flag.String("nginx.channel-include", "", "Regexp of channel names to include. Channels not matching are dropped before any other filter.")
+		nginxChannelExclude   = flag.String("nginx.channel-exclude", "", "Regexp of channel names to exclude.")
+		nginxMaxChannels      = flag.Int("nginx.max-channels", 0, "Hard cap on per-channel series emitted per scrape. 0 means unlimited. Channels over the cap are dropped and counted in nginx_push_stream_channels_dropped.")
+		nginxChannelAggregate = flag.String("nginx.channel-aggregate", "", "Regexp with one capture group; channels sharing a captured value are collapsed into a single series labeled with that value.")
+		nginxCacheTTL         = flag.Duration("nginx.cache-ttl", 0, "How long to cache scraped stats for. 0 disables caching, so every Collect fetches from nginx.")
+		configFile            = flag.String("config.file", "", "Path to a YAML file defining named modules for /probe?target=...&module=....")
 		//nginxPidFile            = kingpin.Flag("nginx.pid-file", pidFileHelpText).Default("").String()
 	)
 
@@ -252,15 +419,58 @@ func main() {
 
 	selectedPushStreamMetrics := filterMetrics(*nginxMetricFields)
 
-	exporter, err := NewExporter(*nginxScrapeURI, selectedPushStreamMetrics, *nginxTimeout)
+	channelFilter := ChannelFilter{MaxChannels: *nginxMaxChannels}
+	if *nginxChannelInclude != "" {
+		re, err := regexp.Compile(*nginxChannelInclude)
+		if err != nil {
+			log.Fatalf("Invalid -nginx.channel-include: %v", err)
+		}
+		channelFilter.Include = re
+	}
+	if *nginxChannelExclude != "" {
+		re, err := regexp.Compile(*nginxChannelExclude)
+		if err != nil {
+			log.Fatalf("Invalid -nginx.channel-exclude: %v", err)
+		}
+		channelFilter.Exclude = re
+	}
+	if *nginxChannelAggregate != "" {
+		re, err := regexp.Compile(*nginxChannelAggregate)
+		if err != nil {
+			log.Fatalf("Invalid -nginx.channel-aggregate: %v", err)
+		}
+		channelFilter.Aggregate = re
+	}
+
+	clientConfig := HTTPClientConfig{
+		InsecureSkipVerify: *insecure,
+		CAFile:             *tlsCAFile,
+		CertFile:           *tlsCertFile,
+		KeyFile:            *tlsKeyFile,
+		BearerTokenFile:    *authBearerToken,
+	}
+	if *authUser != "" {
+		clientConfig.BasicAuth = &BasicAuthConfig{User: *authUser, Pass: *authPass}
+	}
+
+	exporter, err := NewExporter(*nginxScrapeURI, selectedPushStreamMetrics, *nginxTimeout, clientConfig, *nginxModule, channelFilter, *nginxCacheTTL)
 	if err != nil {
 		log.Fatal(err)
 	}
 	prometheus.MustRegister(exporter)
 	prometheus.MustRegister(version.NewCollector("nginx_push_stream_exporter"))
 
+	probeConfig := &ProbeConfig{}
+	if *configFile != "" {
+		probeConfig, err = LoadProbeConfig(*configFile)
+		if err != nil {
+			log.Fatalf("Error loading -config.file: %v", err)
+		}
+	}
+
 	log.Infoln("Listening on", *listenAddress)
 	http.Handle(*metricsPath, promhttp.Handler())
+	http.HandleFunc("/probe", probeHandler(probeConfig, selectedPushStreamMetrics))
 	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		w.Write([]byte(`<html>
              <head><title>Nginx PushStream Exporter</title></head>
@@ -0,0 +1,30 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/tkliner/nginx_push_stream_exporter/pushstream"
+)
+
+// BenchmarkEmit10kChannels exercises the typed extractor registry against
+// 10k channels, the scale push_stream deployments routinely see.
+func BenchmarkEmit10kChannels(b *testing.B) {
+	infos := make([]pushstream.ChannelStats, 10000)
+	for i := range infos {
+		infos[i] = pushstream.ChannelStats{
+			Name:              "channel",
+			PublishedMessages: int64(i),
+			StoredMessages:    int64(i),
+			Subscribers:       int64(i % 7),
+		}
+	}
+	stats := &pushstream.Stats{Channels: int64(len(infos)), Infos: infos}
+
+	serverMetrics, channelMetrics := buildMetricRegistry(pushStreamMetrics)
+	e := &Exporter{serverMetrics: serverMetrics, channelMetrics: channelMetrics}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		e.emit(stats, infos)
+	}
+}
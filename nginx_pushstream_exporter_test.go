@@ -0,0 +1,84 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/tkliner/nginx_push_stream_exporter/pushstream"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+)
+
+func metricValue(t *testing.T, m *prometheus.Metric) (float64, string) {
+	t.Helper()
+	var pb dto.Metric
+	if err := (*m).Write(&pb); err != nil {
+		t.Fatalf("Write() error: %v", err)
+	}
+	if len(pb.Label) != 1 {
+		t.Fatalf("metric has %d labels, want 1", len(pb.Label))
+	}
+	return pb.Gauge.GetValue(), pb.Label[0].GetValue()
+}
+
+func TestBuildMetricRegistry(t *testing.T) {
+	selected := map[string]*prometheus.Desc{
+		"channels":           pushStreamMetrics["channels"],
+		"subscribers_total":  pushStreamMetrics["subscribers_total"],
+		"published_messages": pushStreamMetrics["published_messages"],
+	}
+
+	serverMetrics, channelMetrics := buildMetricRegistry(selected)
+
+	if len(serverMetrics) != 2 {
+		t.Fatalf("len(serverMetrics) = %d, want 2", len(serverMetrics))
+	}
+	if len(channelMetrics) != 1 {
+		t.Fatalf("len(channelMetrics) = %d, want 1", len(channelMetrics))
+	}
+}
+
+func TestEmit(t *testing.T) {
+	selected := map[string]*prometheus.Desc{
+		"channels":           pushStreamMetrics["channels"],
+		"subscribers_total":  pushStreamMetrics["subscribers_total"],
+		"published_messages": pushStreamMetrics["published_messages"],
+	}
+	serverMetrics, channelMetrics := buildMetricRegistry(selected)
+	e := &Exporter{serverMetrics: serverMetrics, channelMetrics: channelMetrics}
+
+	infos := []pushstream.ChannelStats{
+		{Name: "a", PublishedMessages: 10, StoredMessages: 1, Subscribers: 3},
+		{Name: "b", PublishedMessages: 20, StoredMessages: 2, Subscribers: 5},
+	}
+	stats := &pushstream.Stats{Channels: 2, Infos: infos}
+
+	got := e.emit(stats, infos)
+	if want := len(serverMetrics) + len(infos)*len(channelMetrics); len(got) != want {
+		t.Fatalf("len(emit()) = %d, want %d", len(got), want)
+	}
+
+	serverValues := map[*prometheus.Desc]float64{}
+	channelValues := map[string]float64{}
+	for _, m := range got {
+		value, label := metricValue(t, &m)
+		if label == "all" {
+			serverValues[m.Desc()] = value
+			continue
+		}
+		channelValues[label] = value
+	}
+
+	if v := serverValues[pushStreamMetrics["channels"]]; v != 2 {
+		t.Errorf("channels = %v, want 2", v)
+	}
+	if v := serverValues[pushStreamMetrics["subscribers_total"]]; v != 8 {
+		t.Errorf("subscribers_total = %v, want 8", v)
+	}
+	if channelValues["a"] != 10 {
+		t.Errorf("published_messages[a] = %v, want 10", channelValues["a"])
+	}
+	if channelValues["b"] != 20 {
+		t.Errorf("published_messages[b] = %v, want 20", channelValues["b"])
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+	"gopkg.in/yaml.v2"
+)
+
+// ProbeModule describes one named nginx target flavor a /probe request can
+// select, mirroring the blackbox_exporter/snmp_exporter multi-target
+// pattern: the static -nginx.scrape-uri flag keeps driving the exporter's
+// own /metrics, while -config.file modules drive ad-hoc /probe requests
+// against other hosts.
+type ProbeModule struct {
+	// PathTemplate is formatted with the requested target, e.g.
+	// "http://%s/channels-stats?id=ALL".
+	PathTemplate string           `yaml:"path_template"`
+	NginxModule  string           `yaml:"nginx_module"`
+	Timeout      time.Duration    `yaml:"timeout"`
+	Client       HTTPClientConfig `yaml:"client"`
+}
+
+// ProbeConfig is the top-level shape of -config.file.
+type ProbeConfig struct {
+	Modules map[string]ProbeModule `yaml:"modules"`
+}
+
+// LoadProbeConfig reads and parses a -config.file.
+func LoadProbeConfig(path string) (*ProbeConfig, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var cfg ProbeConfig
+	if err := yaml.Unmarshal(b, &cfg); err != nil {
+		return nil, fmt.Errorf("parsing %s: %v", path, err)
+	}
+
+	return &cfg, nil
+}
+
+// probeHandler returns an http.HandlerFunc that scrapes ?target= using the
+// ?module= definition from cfg and renders the result as if it were that
+// target's own /metrics.
+func probeHandler(cfg *ProbeConfig, selectedMetrics map[string]*prometheus.Desc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		target := r.URL.Query().Get("target")
+		if target == "" {
+			http.Error(w, "target parameter is required", http.StatusBadRequest)
+			return
+		}
+
+		moduleName := r.URL.Query().Get("module")
+		if moduleName == "" {
+			moduleName = "default"
+		}
+
+		module, ok := cfg.Modules[moduleName]
+		if !ok {
+			http.Error(w, fmt.Sprintf("unknown module %q", moduleName), http.StatusBadRequest)
+			return
+		}
+
+		timeout := module.Timeout
+		if timeout == 0 {
+			timeout = 5 * time.Second
+		}
+
+		uri := fmt.Sprintf(module.PathTemplate, target)
+		exporter, err := NewExporter(uri, selectedMetrics, timeout, module.Client, module.NginxModule, ChannelFilter{}, 0)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		registry := prometheus.NewRegistry()
+		registry.MustRegister(exporter)
+		promhttp.HandlerFor(registry, promhttp.HandlerOpts{}).ServeHTTP(w, r)
+	}
+}
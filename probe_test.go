@@ -0,0 +1,71 @@
+package main
+
+import (
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLoadProbeConfig(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yml")
+	body := `
+modules:
+  default:
+    path_template: "http://%s/channels-stats?id=ALL"
+    nginx_module: push_stream
+    timeout: 5s
+`
+	if err := os.WriteFile(path, []byte(body), 0644); err != nil {
+		t.Fatalf("WriteFile() error: %v", err)
+	}
+
+	cfg, err := LoadProbeConfig(path)
+	if err != nil {
+		t.Fatalf("LoadProbeConfig() error: %v", err)
+	}
+
+	module, ok := cfg.Modules["default"]
+	if !ok {
+		t.Fatalf("Modules = %v, want a %q entry", cfg.Modules, "default")
+	}
+	if module.PathTemplate != "http://%s/channels-stats?id=ALL" {
+		t.Errorf("PathTemplate = %q, want %q", module.PathTemplate, "http://%s/channels-stats?id=ALL")
+	}
+	if module.NginxModule != "push_stream" {
+		t.Errorf("NginxModule = %q, want %q", module.NginxModule, "push_stream")
+	}
+}
+
+func TestLoadProbeConfigMissingFile(t *testing.T) {
+	if _, err := LoadProbeConfig(filepath.Join(t.TempDir(), "missing.yml")); err == nil {
+		t.Fatal("LoadProbeConfig() error = nil, want error")
+	}
+}
+
+func TestProbeHandlerMissingTarget(t *testing.T) {
+	cfg := &ProbeConfig{Modules: map[string]ProbeModule{"default": {PathTemplate: "http://%s/channels-stats?id=ALL"}}}
+	handler := probeHandler(cfg, pushStreamMetrics)
+
+	req := httptest.NewRequest("GET", "/probe", nil)
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+
+	if rec.Code != 400 {
+		t.Errorf("status = %d, want 400", rec.Code)
+	}
+}
+
+func TestProbeHandlerUnknownModule(t *testing.T) {
+	cfg := &ProbeConfig{Modules: map[string]ProbeModule{"default": {PathTemplate: "http://%s/channels-stats?id=ALL"}}}
+	handler := probeHandler(cfg, pushStreamMetrics)
+
+	req := httptest.NewRequest("GET", "/probe?target=127.0.0.1&module=nope", nil)
+	rec := httptest.NewRecorder()
+	handler(rec, req)
+
+	if rec.Code != 400 {
+		t.Errorf("status = %d, want 400", rec.Code)
+	}
+}
@@ -0,0 +1,117 @@
+package pushstream
+
+import (
+	"encoding/json"
+	"io"
+)
+
+// PlusDecoder decodes the JSON served by nginx-plus's status API
+// (http://nginx.org/en/docs/http/ngx_http_api_module.html). Each http and
+// stream server zone, each upstream peer and each cache becomes a
+// channel-shaped entry so it can flow through the same metric set as
+// push_stream. For zones and peers, PublishedMessages tracks requests
+// handled, StoredMessages tracks bytes sent, and Subscribers tracks
+// currently active connections; for caches they track hit responses, hit
+// bytes and cache size.
+type PlusDecoder struct{}
+
+type plusPayload struct {
+	ServerZones map[string]plusServerZone `json:"server_zones"`
+	Upstreams   map[string]plusUpstream   `json:"upstreams"`
+	Stream      plusStream                `json:"stream"`
+	Caches      map[string]plusCache      `json:"caches"`
+}
+
+type plusServerZone struct {
+	Requests  int64 `json:"requests"`
+	Responses struct {
+		Total int64 `json:"total"`
+	} `json:"responses"`
+	Sent   int64 `json:"sent"`
+	Active int64 `json:"processing"`
+}
+
+type plusUpstream struct {
+	Peers []plusPeer `json:"peers"`
+}
+
+type plusPeer struct {
+	Server   string `json:"server"`
+	Requests int64  `json:"requests"`
+	Sent     int64  `json:"sent"`
+	Active   int64  `json:"active"`
+}
+
+type plusStream struct {
+	ServerZones map[string]plusServerZone `json:"server_zones"`
+	Upstreams   map[string]plusUpstream   `json:"upstreams"`
+}
+
+type plusCache struct {
+	Size int64 `json:"size"`
+	Hit  struct {
+		Responses int64 `json:"responses"`
+		Bytes     int64 `json:"bytes"`
+	} `json:"hit"`
+}
+
+// Decode implements StatsDecoder.
+func (d *PlusDecoder) Decode(r io.Reader) (*Stats, error) {
+	var payload plusPayload
+	if err := json.NewDecoder(r).Decode(&payload); err != nil {
+		return nil, err
+	}
+
+	stats := &Stats{}
+
+	for name, zone := range payload.ServerZones {
+		stats.Infos = append(stats.Infos, ChannelStats{
+			Name:              name,
+			PublishedMessages: zone.Requests,
+			StoredMessages:    zone.Sent,
+			Subscribers:       zone.Active,
+		})
+	}
+	for name, zone := range payload.Stream.ServerZones {
+		stats.Infos = append(stats.Infos, ChannelStats{
+			Name:              "stream/" + name,
+			PublishedMessages: zone.Requests,
+			StoredMessages:    zone.Sent,
+			Subscribers:       zone.Active,
+		})
+	}
+
+	for name, upstream := range payload.Upstreams {
+		for _, peer := range upstream.Peers {
+			stats.Infos = append(stats.Infos, ChannelStats{
+				Name:              name + "/" + peer.Server,
+				PublishedMessages: peer.Requests,
+				StoredMessages:    peer.Sent,
+				Subscribers:       peer.Active,
+			})
+		}
+	}
+	for name, upstream := range payload.Stream.Upstreams {
+		for _, peer := range upstream.Peers {
+			stats.Infos = append(stats.Infos, ChannelStats{
+				Name:              "stream/" + name + "/" + peer.Server,
+				PublishedMessages: peer.Requests,
+				StoredMessages:    peer.Sent,
+				Subscribers:       peer.Active,
+			})
+		}
+	}
+
+	for name, cache := range payload.Caches {
+		stats.Infos = append(stats.Infos, ChannelStats{
+			Name:              "cache/" + name,
+			PublishedMessages: cache.Hit.Responses,
+			StoredMessages:    cache.Hit.Bytes,
+			Subscribers:       cache.Size,
+		})
+	}
+
+	stats.Channels = int64(len(stats.Infos))
+
+	return stats, nil
+}
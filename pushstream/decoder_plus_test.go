@@ -0,0 +1,88 @@
+package pushstream
+
+import (
+	"sort"
+	"strings"
+	"testing"
+)
+
+func TestPlusDecoder(t *testing.T) {
+	body := `{
+		"server_zones": {
+			"zone1": {"requests": 100, "responses": {"total": 95}, "sent": 5000, "processing": 2}
+		},
+		"upstreams": {
+			"backend": {"peers": [
+				{"server": "10.0.0.1:80", "requests": 50, "sent": 2000, "active": 1}
+			]}
+		},
+		"stream": {
+			"server_zones": {
+				"tcp1": {"requests": 10, "responses": {"total": 10}, "sent": 100, "processing": 0}
+			},
+			"upstreams": {
+				"tcp_backend": {"peers": [
+					{"server": "10.0.0.2:53", "requests": 4, "sent": 40, "active": 0}
+				]}
+			}
+		},
+		"caches": {
+			"cache1": {"size": 1024, "hit": {"responses": 7, "bytes": 700}}
+		}
+	}`
+
+	d := &PlusDecoder{}
+	got, err := d.Decode(strings.NewReader(body))
+	if err != nil {
+		t.Fatalf("Decode() unexpected error: %v", err)
+	}
+
+	want := map[string]ChannelStats{
+		"zone1":                          {Name: "zone1", PublishedMessages: 100, StoredMessages: 5000, Subscribers: 2},
+		"stream/tcp1":                    {Name: "stream/tcp1", PublishedMessages: 10, StoredMessages: 100, Subscribers: 0},
+		"backend/10.0.0.1:80":            {Name: "backend/10.0.0.1:80", PublishedMessages: 50, StoredMessages: 2000, Subscribers: 1},
+		"stream/tcp_backend/10.0.0.2:53": {Name: "stream/tcp_backend/10.0.0.2:53", PublishedMessages: 4, StoredMessages: 40, Subscribers: 0},
+		"cache/cache1":                   {Name: "cache/cache1", PublishedMessages: 7, StoredMessages: 700, Subscribers: 1024},
+	}
+
+	if int(got.Channels) != len(want) {
+		t.Fatalf("Channels = %d, want %d", got.Channels, len(want))
+	}
+
+	names := make([]string, 0, len(got.Infos))
+	byName := map[string]ChannelStats{}
+	for _, c := range got.Infos {
+		names = append(names, c.Name)
+		byName[c.Name] = c
+	}
+	sort.Strings(names)
+
+	for name, wantChannel := range want {
+		c, ok := byName[name]
+		if !ok {
+			t.Errorf("missing channel %q in result (got %v)", name, names)
+			continue
+		}
+		if c != wantChannel {
+			t.Errorf("channel %q = %+v, want %+v", name, c, wantChannel)
+		}
+	}
+}
+
+func TestPlusDecoderMalformedJSON(t *testing.T) {
+	d := &PlusDecoder{}
+	if _, err := d.Decode(strings.NewReader(`{"server_zones":`)); err == nil {
+		t.Fatal("Decode() error = nil, want error")
+	}
+}
+
+func TestPlusDecoderEmptyPayload(t *testing.T) {
+	d := &PlusDecoder{}
+	got, err := d.Decode(strings.NewReader(`{}`))
+	if err != nil {
+		t.Fatalf("Decode() unexpected error: %v", err)
+	}
+	if got.Channels != 0 || len(got.Infos) != 0 {
+		t.Errorf("Decode() = %+v, want empty Stats", got)
+	}
+}
@@ -0,0 +1,68 @@
+package pushstream
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strconv"
+)
+
+// PushStreamDecoder decodes the JSON served by nginx's push_stream module
+// channels-stats endpoint. All counters are transmitted as JSON strings, so
+// they are parsed with strconv rather than relying on json.Unmarshal to
+// coerce them.
+type PushStreamDecoder struct{}
+
+type pushStreamPayload struct {
+	Channels string              `json:"channels"`
+	Infos    []pushStreamChannel `json:"infos"`
+}
+
+type pushStreamChannel struct {
+	Channel           string `json:"channel"`
+	PublishedMessages string `json:"published_messages"`
+	StoredMessages    string `json:"stored_messages"`
+	Subscribers       string `json:"subscribers"`
+}
+
+// Decode implements StatsDecoder.
+func (d *PushStreamDecoder) Decode(r io.Reader) (*Stats, error) {
+	var payload pushStreamPayload
+	if err := json.NewDecoder(r).Decode(&payload); err != nil {
+		return nil, err
+	}
+
+	channels, err := strconv.ParseInt(payload.Channels, 10, 64)
+	if err != nil {
+		return nil, fmt.Errorf("parsing channels: %v", err)
+	}
+
+	stats := &Stats{
+		Channels: channels,
+		Infos:    make([]ChannelStats, 0, len(payload.Infos)),
+	}
+
+	for _, c := range payload.Infos {
+		published, err := strconv.ParseInt(c.PublishedMessages, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parsing published_messages for channel %q: %v", c.Channel, err)
+		}
+		stored, err := strconv.ParseInt(c.StoredMessages, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parsing stored_messages for channel %q: %v", c.Channel, err)
+		}
+		subscribers, err := strconv.ParseInt(c.Subscribers, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("parsing subscribers for channel %q: %v", c.Channel, err)
+		}
+
+		stats.Infos = append(stats.Infos, ChannelStats{
+			Name:              c.Channel,
+			PublishedMessages: published,
+			StoredMessages:    stored,
+			Subscribers:       subscribers,
+		})
+	}
+
+	return stats, nil
+}
@@ -0,0 +1,88 @@
+package pushstream
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestPushStreamDecoder(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		want    *Stats
+		wantErr bool
+	}{
+		{
+			name: "valid payload",
+			body: `{"channels":"2","infos":[
+				{"channel":"a","published_messages":"10","stored_messages":"1","subscribers":"3"},
+				{"channel":"b","published_messages":"20","stored_messages":"2","subscribers":"5"}
+			]}`,
+			want: &Stats{
+				Channels: 2,
+				Infos: []ChannelStats{
+					{Name: "a", PublishedMessages: 10, StoredMessages: 1, Subscribers: 3},
+					{Name: "b", PublishedMessages: 20, StoredMessages: 2, Subscribers: 5},
+				},
+			},
+		},
+		{
+			name: "empty channel list",
+			body: `{"channels":"0","infos":[]}`,
+			want: &Stats{Channels: 0, Infos: []ChannelStats{}},
+		},
+		{
+			name:    "non-numeric channels field",
+			body:    `{"channels":"not-a-number","infos":[]}`,
+			wantErr: true,
+		},
+		{
+			name:    "non-numeric channel counter",
+			body:    `{"channels":"1","infos":[{"channel":"a","published_messages":"nope","stored_messages":"1","subscribers":"3"}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "missing channel counter",
+			body:    `{"channels":"1","infos":[{"channel":"a","stored_messages":"1","subscribers":"3"}]}`,
+			wantErr: true,
+		},
+		{
+			name:    "malformed JSON",
+			body:    `{"channels":`,
+			wantErr: true,
+		},
+		{
+			name:    "wrong JSON type for infos",
+			body:    `{"channels":"1","infos":"not-an-array"}`,
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			d := &PushStreamDecoder{}
+			got, err := d.Decode(strings.NewReader(tc.body))
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("Decode() error = nil, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Decode() unexpected error: %v", err)
+			}
+
+			if got.Channels != tc.want.Channels {
+				t.Errorf("Channels = %d, want %d", got.Channels, tc.want.Channels)
+			}
+			if len(got.Infos) != len(tc.want.Infos) {
+				t.Fatalf("len(Infos) = %d, want %d", len(got.Infos), len(tc.want.Infos))
+			}
+			for i, c := range got.Infos {
+				if c != tc.want.Infos[i] {
+					t.Errorf("Infos[%d] = %+v, want %+v", i, c, tc.want.Infos[i])
+				}
+			}
+		})
+	}
+}
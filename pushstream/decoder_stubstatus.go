@@ -0,0 +1,72 @@
+package pushstream
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+)
+
+// StubStatusDecoder decodes nginx's plain-text stub_status module output:
+//
+//	Active connections: 1
+//	server accepts handled requests
+//	 16 16 16
+//	Reading: 0 Writing: 1 Waiting: 0
+//
+// stub_status has no concept of channels, so the whole response becomes a
+// single server-wide entry with no per-channel breakdown.
+type StubStatusDecoder struct{}
+
+// Decode implements StatsDecoder.
+func (d *StubStatusDecoder) Decode(r io.Reader) (*Stats, error) {
+	var active, handled, requests int64
+
+	scanner := bufio.NewScanner(r)
+	lineNo := 0
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		lineNo++
+
+		switch {
+		case strings.HasPrefix(line, "Active connections:"):
+			fields := strings.Fields(line)
+			if len(fields) < 3 {
+				return nil, fmt.Errorf("malformed stub_status active connections line: %q", line)
+			}
+			n, err := strconv.ParseInt(fields[2], 10, 64)
+			if err != nil {
+				return nil, fmt.Errorf("parsing active connections: %v", err)
+			}
+			active = n
+		case lineNo == 3:
+			fields := strings.Fields(line)
+			if len(fields) != 3 {
+				continue
+			}
+			var err error
+			if handled, err = strconv.ParseInt(fields[1], 10, 64); err != nil {
+				return nil, fmt.Errorf("parsing handled: %v", err)
+			}
+			if requests, err = strconv.ParseInt(fields[2], 10, 64); err != nil {
+				return nil, fmt.Errorf("parsing requests: %v", err)
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	return &Stats{
+		Channels: 1,
+		Infos: []ChannelStats{
+			{
+				Name:              "stub_status",
+				PublishedMessages: requests,
+				StoredMessages:    handled,
+				Subscribers:       active,
+			},
+		},
+	}, nil
+}
@@ -0,0 +1,56 @@
+package pushstream
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestStubStatusDecoder(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		want    ChannelStats
+		wantErr bool
+	}{
+		{
+			name: "typical output",
+			body: "Active connections: 3 \n" +
+				"server accepts handled requests\n" +
+				" 16 16 48 \n" +
+				"Reading: 0 Writing: 1 Waiting: 2 \n",
+			want: ChannelStats{Name: "stub_status", PublishedMessages: 48, StoredMessages: 16, Subscribers: 3},
+		},
+		{
+			name:    "malformed active connections line",
+			body:    "Active connections:\nserver accepts handled requests\n 16 16 48\n",
+			wantErr: true,
+		},
+		{
+			name:    "non-numeric counters line",
+			body:    "Active connections: 3\nserver accepts handled requests\n a b c\n",
+			wantErr: true,
+		},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.name, func(t *testing.T) {
+			d := &StubStatusDecoder{}
+			got, err := d.Decode(strings.NewReader(tc.body))
+			if tc.wantErr {
+				if err == nil {
+					t.Fatalf("Decode() error = nil, want error")
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Decode() unexpected error: %v", err)
+			}
+			if got.Channels != 1 || len(got.Infos) != 1 {
+				t.Fatalf("Decode() = %+v, want a single stub_status entry", got)
+			}
+			if got.Infos[0] != tc.want {
+				t.Errorf("Infos[0] = %+v, want %+v", got.Infos[0], tc.want)
+			}
+		})
+	}
+}
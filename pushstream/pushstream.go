@@ -1,17 +1,54 @@
+// Package pushstream decodes stats payloads served by nginx's push_stream
+// module (and compatible modules) into a common Stats representation that
+// the exporter can turn into Prometheus metrics without caring which
+// upstream flavor produced them.
 package pushstream
 
-type PushStream struct {
-	Channels string `json:"channels"`
-	Infos []*Channel `json:"infos"`
+import "io"
+
+// ChannelStats holds the per-channel counters reported by a channel-based
+// module such as push_stream.
+type ChannelStats struct {
+	Name              string
+	PublishedMessages int64
+	StoredMessages    int64
+	Subscribers       int64
+}
+
+// Stats is the decoder-agnostic set of metrics scraped from an nginx stats
+// endpoint.
+type Stats struct {
+	Channels int64
+	Infos    []ChannelStats
+}
+
+// StatsDecoder decodes a particular nginx stats module's payload into the
+// common Stats representation.
+type StatsDecoder interface {
+	Decode(r io.Reader) (*Stats, error)
+}
+
+// NewDecoder returns the StatsDecoder for the named upstream module.
+// Supported modules are "push_stream", "plus" and "stub_status".
+func NewDecoder(module string) (StatsDecoder, error) {
+	switch module {
+	case "", "push_stream":
+		return &PushStreamDecoder{}, nil
+	case "plus":
+		return &PlusDecoder{}, nil
+	case "stub_status":
+		return &StubStatusDecoder{}, nil
+	default:
+		return nil, &UnsupportedModuleError{Module: module}
+	}
 }
 
-type Channel struct {
-	Channel           string `json:"channel"`
-	PublishedMessages string `json:"published_messages"`
-	StoredMessages    string `json:"stored_messages"`
-	Subscribers       string `json:"subscribers"`
+// UnsupportedModuleError is returned by NewDecoder for an unknown module
+// name.
+type UnsupportedModuleError struct {
+	Module string
 }
 
-func NewPushStream() *PushStream {
-	return &PushStream{}
+func (e *UnsupportedModuleError) Error() string {
+	return "unsupported nginx module: " + e.Module
 }